@@ -0,0 +1,132 @@
+// Package cache memoizes rendered tile PNGs on disk, keyed by the
+// parameters that affect their pixels, and deduplicates byte-identical
+// tiles (common in the Mandelbrot set's interior) with hardlinks instead of
+// storing the same bytes twice.
+package cache
+
+import (
+	"crypto/sha1"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// recentHashes bounds how many distinct hashes Cache remembers as hardlink
+// candidates.
+const recentHashes = 4096
+
+// Key identifies a rendered tile. Two tiles with the same Key are assumed to
+// render to identical pixels.
+type Key struct {
+	Z, X, Y  int
+	Palette  string
+	MaxIter  int
+	Overlays string // the -overlays flag value in effect when the tile was rendered
+}
+
+func (k Key) path(dir string) string {
+	overlays := k.Overlays
+	if overlays == "" {
+		overlays = "none"
+	}
+	return filepath.Join(
+		dir,
+		k.Palette,
+		strconv.Itoa(k.MaxIter),
+		overlays,
+		strconv.Itoa(k.Z),
+		strconv.Itoa(k.X),
+		strconv.Itoa(k.Y)+".png",
+	)
+}
+
+// Cache stores rendered tile PNGs under a directory on disk.
+type Cache struct {
+	dir string
+
+	mu     sync.Mutex
+	hashes *hashLRU
+}
+
+// New returns a Cache that stores tiles under dir, creating it if it
+// doesn't already exist.
+func New(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{
+		dir:    dir,
+		hashes: newHashLRU(recentHashes),
+	}, nil
+}
+
+// Get returns the cached PNG bytes for key, if present.
+func (c *Cache) Get(key Key) (data []byte, ok bool, err error) {
+	data, err = os.ReadFile(key.path(c.dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Put stores png under key. If png's content hash matches a recently
+// written tile, the file is hardlinked to that tile's bytes rather than
+// written out again.
+func (c *Cache) Put(key Key, png []byte) error {
+	path := key.path(c.dir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	sum := sha1.Sum(png)
+
+	c.mu.Lock()
+	existing, ok := c.hashes.get(sum)
+	c.mu.Unlock()
+
+	if ok {
+		if err := os.Link(existing, path); err == nil {
+			return nil
+		}
+		// The link target may have been removed, or dir may span a
+		// different filesystem. Fall back to a normal write below.
+	}
+
+	if err := writeFileAtomic(path, png, 0o644); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.hashes.put(sum, path)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// writeFileAtomic writes data to path by writing to a temporary file in the
+// same directory and renaming it into place, so a concurrent Get never
+// observes a partially written file at path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
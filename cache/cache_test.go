@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetMissThenPutThenGetHit(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Key{Z: 3, X: 1, Y: 2, Palette: "default", MaxIter: 1000}
+
+	if _, ok, err := c.Get(key); err != nil || ok {
+		t.Fatalf("Get before Put: ok=%v err=%v, want ok=false err=nil", ok, err)
+	}
+
+	want := []byte("fake png bytes")
+	if err := c.Put(key, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := c.Get(key)
+	if err != nil || !ok {
+		t.Fatalf("Get after Put: ok=%v err=%v, want ok=true err=nil", ok, err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPutDeduplicatesIdenticalBytesWithHardlink(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("all-black interior tile")
+	keyA := Key{Z: 10, X: 5, Y: 5, Palette: "default", MaxIter: 1000}
+	keyB := Key{Z: 10, X: 5, Y: 6, Palette: "default", MaxIter: 1000}
+
+	if err := c.Put(keyA, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(keyB, data); err != nil {
+		t.Fatal(err)
+	}
+
+	infoA, err := os.Stat(keyA.path(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	infoB, err := os.Stat(keyB.path(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(infoA, infoB) {
+		t.Error("expected the two identical tiles to be hardlinked to the same inode")
+	}
+}
+
+func TestPutLeavesNoTemporaryFilesBehind(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Key{Z: 4, X: 0, Y: 0, Palette: "default", MaxIter: 1000}
+
+	if err := c.Put(key, []byte("first version")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(key, []byte("second version")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := c.Get(key)
+	if err != nil || !ok {
+		t.Fatalf("Get: ok=%v err=%v, want ok=true err=nil", ok, err)
+	}
+	if string(got) != "second version" {
+		t.Errorf("got %q, want %q", got, "second version")
+	}
+
+	// Put writes via a temp file in the same directory before renaming
+	// into place; none of those temp files should survive a successful
+	// Put, since a leftover temp file there would mean Get could have
+	// raced against a half-written rename.
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) != ".png" {
+			t.Errorf("unexpected non-png file left behind: %s", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyPathIsStable(t *testing.T) {
+	key := Key{Z: 1, X: 2, Y: 3, Palette: "plan9", MaxIter: 1000}
+	want := filepath.Join("root", "plan9", "1000", "none", "1", "2", "3.png")
+	if got := key.path("root"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestKeyPathVariesWithOverlays(t *testing.T) {
+	withOverlays := Key{Z: 1, X: 2, Y: 3, Palette: "plan9", MaxIter: 1000, Overlays: "grid,axes"}
+	withoutOverlays := Key{Z: 1, X: 2, Y: 3, Palette: "plan9", MaxIter: 1000}
+	if withOverlays.path("root") == withoutOverlays.path("root") {
+		t.Error("expected different overlay settings to map to different cache paths")
+	}
+}
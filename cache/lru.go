@@ -0,0 +1,53 @@
+package cache
+
+import "container/list"
+
+// hashLRU is a fixed-size, least-recently-used cache mapping a PNG's SHA-1
+// digest to the path of the first file on disk that holds those bytes. It
+// exists purely to let Cache.Put find a hardlink candidate; once an entry
+// is evicted, a future matching tile is simply written out again rather
+// than linked.
+type hashLRU struct {
+	capacity int
+	entries  map[[20]byte]*list.Element
+	order    *list.List // front = most recently used
+}
+
+type hashLRUEntry struct {
+	sum  [20]byte
+	path string
+}
+
+func newHashLRU(capacity int) *hashLRU {
+	return &hashLRU{
+		capacity: capacity,
+		entries:  make(map[[20]byte]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *hashLRU) get(sum [20]byte) (string, bool) {
+	elem, ok := c.entries[sum]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*hashLRUEntry).path, true
+}
+
+func (c *hashLRU) put(sum [20]byte, path string) {
+	if elem, ok := c.entries[sum]; ok {
+		elem.Value.(*hashLRUEntry).path = path
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	elem := c.order.PushFront(&hashLRUEntry{sum: sum, path: path})
+	c.entries[sum] = elem
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*hashLRUEntry).sum)
+	}
+}
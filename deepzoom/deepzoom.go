@@ -0,0 +1,129 @@
+// Package deepzoom implements perturbation-based Mandelbrot rendering for
+// zoom levels where plain float64 arithmetic no longer has enough
+// precision to tell neighbouring pixels apart.
+//
+// Instead of iterating every pixel's coordinate at high precision, a single
+// reference point near the tile is iterated at high precision to produce a
+// ReferenceOrbit. Every other pixel is then iterated as a small delta from
+// that orbit, entirely in float64, which is both correct (to first order)
+// and fast.
+package deepzoom
+
+import (
+	"math"
+	"math/big"
+	"math/cmplx"
+)
+
+// PrecisionBits is the bit precision used for the reference orbit's
+// high-precision arithmetic. 200 bits comfortably resolves tile geometry
+// far past float64's ~53 bits.
+const PrecisionBits = 200
+
+// Threshold is the pixel-spacing threshold below which the naive,
+// non-perturbed renderer loses too much precision to be trustworthy, and
+// the deep-zoom path should be used instead.
+const Threshold = 1e-14
+
+// GlitchFactor controls glitch detection: a pixel's delta orbit is
+// considered glitched once it grows to be within GlitchFactor of the
+// reference orbit's magnitude at the same iteration, since at that point
+// the two orbits are no longer safely distinguishable in float64.
+const GlitchFactor = 1e-3
+
+// maxIterSentinel is returned as the iteration count for points that
+// never escape within the reference orbit's length.
+const maxIterSentinel = 0
+
+// ReferenceOrbit is the sequence of iterates Z_n of a single
+// high-precision reference point C0, computed once per tile and shared by
+// every pixel's delta orbit.
+type ReferenceOrbit struct {
+	Z []complex128
+}
+
+// ComputeReferenceOrbit iterates C0 = re + i*im at PrecisionBits of
+// precision for maxIter steps (continuing even after C0 itself escapes, so
+// that pixels whose delta orbit escapes later than the reference still
+// have reference iterates to perturb against), recording each iterate
+// rounded to a complex128.
+func ComputeReferenceOrbit(re, im *big.Float, maxIter int) ReferenceOrbit {
+	zre := new(big.Float).SetPrec(PrecisionBits)
+	zim := new(big.Float).SetPrec(PrecisionBits)
+
+	orbit := make([]complex128, 0, maxIter)
+	escaped := false
+	for i := 0; i < maxIter; i++ {
+		if !escaped {
+			reSq := new(big.Float).SetPrec(PrecisionBits).Mul(zre, zre)
+			imSq := new(big.Float).SetPrec(PrecisionBits).Mul(zim, zim)
+			newRe := new(big.Float).SetPrec(PrecisionBits).Sub(reSq, imSq)
+			newRe.Add(newRe, re)
+
+			newIm := new(big.Float).SetPrec(PrecisionBits).Mul(zre, zim)
+			newIm.Mul(newIm, big.NewFloat(2))
+			newIm.Add(newIm, im)
+
+			zre, zim = newRe, newIm
+
+			reF, _ := zre.Float64()
+			imF, _ := zim.Float64()
+			if reF*reF+imF*imF > 1e10 {
+				// Well past the escape radius: further iterates would
+				// blow up towards infinity and are useless as a
+				// perturbation reference anyway, so freeze the orbit
+				// here rather than risk overflowing the big.Float ops.
+				escaped = true
+			}
+			orbit = append(orbit, complex(reF, imF))
+			continue
+		}
+		orbit = append(orbit, orbit[len(orbit)-1])
+	}
+	return ReferenceOrbit{Z: orbit}
+}
+
+// IterateDelta computes the smoothed escape iteration count for the pixel
+// at C0+deltaC, given C0's reference orbit, using perturbation theory:
+// rather than iterating C0+deltaC directly at high precision, only the
+// (small) delta from the reference orbit is iterated, entirely in
+// float64.
+//
+// glitched is true if the delta orbit grew too close to the reference
+// orbit's own magnitude for the result to be trusted; the caller should
+// re-render the pixel against a different, nearby reference orbit.
+func IterateDelta(orbit ReferenceOrbit, deltaC complex128) (iter float64, glitched bool) {
+	var deltaZ complex128
+	var zPrev complex128 // Z_i, the reference iterate the recurrence perturbs from; Z_0 = 0.
+	for i := 0; i < len(orbit.Z); i++ {
+		deltaZ = perturbationStep(zPrev, deltaZ, deltaC)
+		z := orbit.Z[i] // Z_{i+1}
+		actual := z + deltaZ
+
+		if cmplx.Abs(actual) < cmplx.Abs(deltaZ)*GlitchFactor {
+			return 0, true
+		}
+
+		if real(actual)*real(actual)+imag(actual)*imag(actual) > 4 {
+			escapedAt := i
+			for k := 0; k < 2 && i+1 < len(orbit.Z); k++ {
+				zPrev = z
+				i++
+				deltaZ = perturbationStep(zPrev, deltaZ, deltaC)
+				z = orbit.Z[i]
+				actual = z + deltaZ
+			}
+			modulus := cmplx.Abs(actual)
+			return float64(escapedAt) - math.Log(math.Log(modulus))/math.Log(2), false
+		}
+		zPrev = z
+	}
+	return maxIterSentinel, false
+}
+
+// perturbationStep advances a pixel's delta orbit by one step of the exact
+// perturbation recurrence deltaZ_{n+1} = 2*Z_n*deltaZ_n + deltaZ_n^2 +
+// deltaC, given the reference orbit's previous iterate Z_n.
+func perturbationStep(zPrev, deltaZ, deltaC complex128) complex128 {
+	return 2*zPrev*deltaZ + deltaZ*deltaZ + deltaC
+}
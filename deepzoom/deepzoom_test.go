@@ -0,0 +1,115 @@
+package deepzoom
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// naiveMandelbrot mirrors the plain float64 escape-time algorithm this
+// package is an alternative to, so that ComputeReferenceOrbit and
+// IterateDelta can be checked against it.
+func naiveMandelbrot(c complex128, maxIter int) float64 {
+	var z complex128
+	for i := 0; i < maxIter; i++ {
+		z = z*z + c
+		if real(z)*real(z)+imag(z)*imag(z) > 4 {
+			z = z*z + c
+			z = z*z + c
+			modulus := real(z)*real(z) + imag(z)*imag(z)
+			return float64(i) - math.Log(math.Log(math.Sqrt(modulus)))/math.Log(2)
+		}
+	}
+	return 0
+}
+
+func TestIterateDeltaAtReferenceMatchesNaive(t *testing.T) {
+	const maxIter = 200
+
+	for _, c := range []complex128{
+		-0.75 + 0.1i,
+		-1.25 + 0i,
+		0.3 + 0.5i,
+	} {
+		re := big.NewFloat(real(c)).SetPrec(PrecisionBits)
+		im := big.NewFloat(imag(c)).SetPrec(PrecisionBits)
+		orbit := ComputeReferenceOrbit(re, im, maxIter)
+
+		got, glitched := IterateDelta(orbit, 0)
+		if glitched {
+			t.Fatalf("IterateDelta(orbit, 0) for c=%v reported a glitch at the reference point itself", c)
+		}
+
+		want := naiveMandelbrot(c, maxIter)
+		if math.Abs(got-want) > 1e-6 {
+			t.Errorf("c=%v: IterateDelta(orbit, 0) = %v, naiveMandelbrot = %v", c, got, want)
+		}
+	}
+}
+
+func TestIterateDeltaNearbyPixelsAgreeWithNaive(t *testing.T) {
+	const maxIter = 200
+	c0 := complex(-0.75, 0.1)
+	re := big.NewFloat(real(c0)).SetPrec(PrecisionBits)
+	im := big.NewFloat(imag(c0)).SetPrec(PrecisionBits)
+	orbit := ComputeReferenceOrbit(re, im, maxIter)
+
+	// A delta much larger than the deep-zoom regime this package targets,
+	// but still small enough that perturbation theory should track the
+	// naive result before any glitch is possible.
+	for _, delta := range []complex128{
+		1e-6 + 0i,
+		0 + 1e-6i,
+		-1e-6 - 1e-6i,
+	} {
+		got, glitched := IterateDelta(orbit, delta)
+		if glitched {
+			t.Fatalf("unexpected glitch for delta=%v", delta)
+		}
+		want := naiveMandelbrot(c0+delta, maxIter)
+		// A 1e-6 nudge can shift which integer iteration an orbit escapes
+		// on, so allow slack comparable to one iteration rather than
+		// requiring the smoothed values to match exactly.
+		if math.Abs(got-want) > 0.5 {
+			t.Errorf("delta=%v: IterateDelta = %v, naiveMandelbrot(c0+delta) = %v", delta, got, want)
+		}
+	}
+}
+
+func TestIterateDeltaDetectsGlitches(t *testing.T) {
+	const maxIter = 50
+
+	// Delta of exactly zero is the reference point itself: its actual orbit
+	// is the reference orbit, so it can never glitch.
+	c0 := complex(-0.75, 0.1)
+	re := big.NewFloat(real(c0)).SetPrec(PrecisionBits)
+	im := big.NewFloat(imag(c0)).SetPrec(PrecisionBits)
+	refOrbit := ComputeReferenceOrbit(re, im, maxIter)
+	if _, glitched := IterateDelta(refOrbit, 0); glitched {
+		t.Fatal("delta of exactly zero should never glitch")
+	}
+
+	// A synthetic reference orbit whose first iterate is Z_1=1, i.e. well
+	// away from the origin. A delta that cancels Z_1 almost exactly drives
+	// the pixel's actual orbit (Z_1+deltaZ_1) near zero while the reference
+	// orbit itself never goes near zero, which is exactly the situation
+	// IterateDelta is supposed to flag rather than trust.
+	glitchOrbit := ReferenceOrbit{Z: []complex128{1, 1, 1, 1, 1}}
+	iter, glitched := IterateDelta(glitchOrbit, -1+1e-6)
+	if !glitched {
+		t.Fatalf("expected a glitch, got iter=%v glitched=%v", iter, glitched)
+	}
+
+	// The re-render path (main.go's renderGlitchedPixel) falls back to a
+	// fresh reference orbit centred on the glitched pixel itself, at which
+	// point the delta is exactly zero and perturbation theory is exact.
+	reReferenced := ComputeReferenceOrbit(big.NewFloat(-1+1e-6).SetPrec(PrecisionBits), big.NewFloat(0).SetPrec(PrecisionBits), maxIter)
+	gotIter, reGlitched := IterateDelta(reReferenced, 0)
+	if reGlitched {
+		t.Fatal("re-referenced orbit should never glitch against itself")
+	}
+	wantIter := naiveMandelbrot(-1+1e-6, maxIter)
+	if math.Abs(gotIter-wantIter) > 1e-6 {
+		t.Errorf("re-referenced IterateDelta = %v, naiveMandelbrot = %v", gotIter, wantIter)
+	}
+}
@@ -1,27 +1,193 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"image"
-	"image/color"
 	"image/png"
 	"log"
 	"math"
+	"math/big"
 	"net/http"
+	"os"
 	"regexp"
+	"runtime"
 	"strconv"
+	"strings"
+
+	"github.com/peterstace/mandelbrottiles/cache"
+	"github.com/peterstace/mandelbrottiles/deepzoom"
+	"github.com/peterstace/mandelbrottiles/overlay"
+	"github.com/peterstace/mandelbrottiles/palette"
+	"github.com/peterstace/mandelbrottiles/render"
+	"github.com/peterstace/mandelbrottiles/tilearchive"
 )
 
+// parseOverlays looks up each comma-separated name in names against the
+// overlay registry, in order. An empty string yields no overlays.
+func parseOverlays(names string) ([]overlay.Overlay, error) {
+	if names == "" {
+		return nil, nil
+	}
+	var overlays []overlay.Overlay
+	for _, name := range strings.Split(names, ",") {
+		o, ok := overlay.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown overlay: %q", name)
+		}
+		overlays = append(overlays, o)
+	}
+	return overlays, nil
+}
+
+// main dispatches to the "pack" and "serve-archive" subcommands, falling
+// back to serving tiles directly (the original, and default, behaviour)
+// when no subcommand is given.
 func main() {
-	listenAddr := flag.String("listen-addr", ":8080", "address to listen for tile requests on")
-	flag.Parse()
-	log.Fatal(http.ListenAndServe(*listenAddr, tileServer()))
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "pack":
+			runPack(os.Args[2:])
+			return
+		case "serve-archive":
+			runServeArchive(os.Args[2:])
+			return
+		}
+	}
+	runServe(os.Args[1:])
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listenAddr := fs.String("listen-addr", ":8080", "address to listen for tile requests on")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of worker goroutines rendering tile rows")
+	paletteName := fs.String("palette", "default", "colour palette to render tiles with (default, plan9, hippi, afternoonblue, fiesta)")
+	cacheDir := fs.String("cache-dir", "", "directory to cache rendered tile PNGs in (disabled if empty)")
+	overlaysFlag := fs.String("overlays", "", "comma-separated overlays to draw on each tile (grid, axes)")
+	fs.Parse(args)
+
+	pal, ok := palette.Lookup(*paletteName)
+	if !ok {
+		log.Fatalf("unknown palette: %q", *paletteName)
+	}
+
+	overlays, err := parseOverlays(*overlaysFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var tileCache *cache.Cache
+	if *cacheDir != "" {
+		tileCache, err = cache.New(*cacheDir)
+		if err != nil {
+			log.Fatalf("opening tile cache: %v", err)
+		}
+	}
+
+	renderer := render.New(*workers)
+	log.Fatal(http.ListenAndServe(*listenAddr, tileServer(renderer, pal, *paletteName, tileCache, overlays, *overlaysFlag)))
+}
+
+// planeBounds is the fixed extent of the complex plane this server ever
+// renders, used as the bounds recorded in a packed archive's header.
+var planeBounds = tileExtent(TileCoords{Z: 0, X: 0, Y: 0})
+
+func runPack(args []string) {
+	fs := flag.NewFlagSet("pack", flag.ExitOnError)
+	zmax := fs.Int("zmax", 10, "maximum zoom level to pre-render")
+	paletteName := fs.String("palette", "default", "colour palette to render tiles with (default, plan9, hippi, afternoonblue, fiesta)")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of worker goroutines rendering tile rows")
+	out := fs.String("out", "mandelbrot.tiles", "path to write the tile archive to")
+	overlaysFlag := fs.String("overlays", "", "comma-separated overlays to draw on each tile (grid, axes)")
+	fs.Parse(args)
+
+	pal, ok := palette.Lookup(*paletteName)
+	if !ok {
+		log.Fatalf("unknown palette: %q", *paletteName)
+	}
+
+	overlays, err := parseOverlays(*overlaysFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	renderer := render.New(*workers)
+	source := func(z, x, y int) ([]byte, error) {
+		tile := renderTile(renderer, pal, TileCoords{Z: z, X: x, Y: y}, overlays)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, tile); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	opts := tilearchive.PackOptions{
+		MinZoom: 0,
+		MaxZoom: *zmax,
+		MinX:    planeBounds.Min.X,
+		MinY:    planeBounds.Min.Y,
+		MaxX:    planeBounds.Max.X,
+		MaxY:    planeBounds.Max.Y,
+	}
+	if err := tilearchive.Pack(f, opts, source); err != nil {
+		log.Fatalf("packing tiles: %v", err)
+	}
+}
+
+func runServeArchive(args []string) {
+	fs := flag.NewFlagSet("serve-archive", flag.ExitOnError)
+	listenAddr := fs.String("listen-addr", ":8080", "address to listen for tile requests on")
+	archivePath := fs.String("archive", "mandelbrot.tiles", "path to the tile archive to serve")
+	fs.Parse(args)
+
+	f, err := os.Open(*archivePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	archive, err := tilearchive.Open(f)
+	if err != nil {
+		log.Fatalf("opening archive: %v", err)
+	}
+
+	log.Fatal(http.ListenAndServe(*listenAddr, archiveServer(archive)))
+}
+
+func archiveServer(archive *tilearchive.Reader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coords, err := extractTileCoords(r.URL.Path)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		data, ok, err := archive.GetTile(uint8(coords.Z), uint32(coords.X), uint32(coords.Y))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
 }
 
 const tileSize = 256
+const maxIter = 1000
 
-func tileServer() http.Handler {
+func tileServer(renderer *render.Renderer, pal palette.Palette, paletteName string, tileCache *cache.Cache, overlays []overlay.Overlay, overlaysFlag string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		coords, err := extractTileCoords(r.URL.Path)
 		if err != nil {
@@ -29,12 +195,33 @@ func tileServer() http.Handler {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
-		tile := renderTile(coords)
-		if err := png.Encode(w, tile); err != nil {
+
+		cacheKey := cache.Key{Z: coords.Z, X: coords.X, Y: coords.Y, Palette: paletteName, MaxIter: maxIter, Overlays: overlaysFlag}
+		if tileCache != nil {
+			if data, ok, err := tileCache.Get(cacheKey); err != nil {
+				log.Println(err)
+			} else if ok {
+				w.Write(data)
+				return
+			}
+		}
+
+		tile := renderTile(renderer, pal, coords, overlays)
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, tile); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("internal server error: " + err.Error()))
 			return
 		}
+
+		if tileCache != nil {
+			if err := tileCache.Put(cacheKey, buf.Bytes()); err != nil {
+				log.Println(err)
+			}
+		}
+
+		w.Write(buf.Bytes())
 	})
 }
 
@@ -73,83 +260,139 @@ func extractTileCoords(path string) (TileCoords, error) {
 	return coords, nil
 }
 
-func renderTile(coords TileCoords) image.Image {
+func renderTile(renderer *render.Renderer, pal palette.Palette, coords TileCoords, overlays []overlay.Overlay) image.Image {
 	extent := tileExtent(coords)
 	tile := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
-	for i := 0; i < tileSize; i++ {
-		for j := 0; j < tileSize; j++ {
-			c := Vector{
-				extent.Min.X + (extent.Max.X-extent.Min.X)*float64(i)/tileSize,
-				extent.Min.Y + (extent.Max.Y-extent.Min.Y)*float64(j)/tileSize,
+
+	pixelSpacing := (extent.Max.X - extent.Min.X) / tileSize
+	if pixelSpacing < deepzoom.Threshold {
+		renderTileDeepZoom(renderer, pal, coords, tile)
+	} else {
+		renderer.RenderRows(tileSize, func(i int) {
+			for j := 0; j < tileSize; j++ {
+				c := Vector{
+					extent.Min.X + (extent.Max.X-extent.Min.X)*float64(i)/tileSize,
+					extent.Min.Y + (extent.Max.Y-extent.Min.Y)*float64(j)/tileSize,
+				}
+				iterationCount := mandelbrot(c)
+				colour := pal.Lookup(iterationCount)
+				tile.SetRGBA(i, j, colour)
 			}
-			iterationCount := mandelbrot(c)
-			colour := escapeColour(iterationCount)
-			tile.SetRGBA(i, j, colour)
+		})
+	}
+
+	if len(overlays) > 0 {
+		oc := overlay.TileCoords{Z: coords.Z, X: coords.X, Y: coords.Y}
+		oe := overlay.Extent{MinX: extent.Min.X, MinY: extent.Min.Y, MaxX: extent.Max.X, MaxY: extent.Max.Y}
+		for _, o := range overlays {
+			o.Draw(tile, oc, oe)
 		}
 	}
+
 	return tile
 }
 
-func escapeColour(iterationCount float64) color.RGBA {
-	iterationCount *= 25 // artistically chosen multiplier
-	return hslToRGB(math.Mod(iterationCount+360, 360), 0.5, 0.5)
-}
+// bigTileGeometry computes a tile's centre and pixel-to-pixel spacing in
+// the complex plane using big.Float arithmetic throughout, mirroring the
+// transform tileExtent applies in float64. It exists because, at the deep
+// zoom levels renderTileDeepZoom handles, tileExtent's plain float64
+// division has already rounded a tile's min and max corners to the same
+// double, so a centre reconstructed from that Extent carries none of the
+// precision the reference orbit needs.
+func bigTileGeometry(coords TileCoords, prec uint) (centerX, centerY, pixelSpacing *big.Float) {
+	scale := new(big.Float).SetPrec(prec).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(coords.Z)))
 
-func hslToRGB(hue, saturation, lightness float64) color.RGBA {
-	if hue < 0 || hue > 360 {
-		panic("hue must be from 0 to 360")
-	}
-	if saturation < 0 || saturation > 1 {
-		panic("saturation must be between 0 and 1")
-	}
-	if lightness < 0 || lightness > 1 {
-		panic("lightness must be between 0 and 1")
+	minX := new(big.Float).SetPrec(prec).Quo(new(big.Float).SetPrec(prec).SetInt64(int64(coords.X)), scale)
+	maxX := new(big.Float).SetPrec(prec).Quo(new(big.Float).SetPrec(prec).SetInt64(int64(coords.X+1)), scale)
+	minY := new(big.Float).SetPrec(prec).Quo(new(big.Float).SetPrec(prec).SetInt64(int64(coords.Y)), scale)
+	maxY := new(big.Float).SetPrec(prec).Quo(new(big.Float).SetPrec(prec).SetInt64(int64(coords.Y+1)), scale)
+
+	half := big.NewFloat(0.5).SetPrec(prec)
+	four := big.NewFloat(4).SetPrec(prec)
+	for _, v := range []*big.Float{minX, maxX, minY, maxY} {
+		v.Sub(v, half).Mul(v, four)
 	}
 
-	c := (1 - math.Abs(2*lightness-1)) * saturation // chroma
-	hueAdj := hue / 60
-	x := c * (1 - math.Abs(math.Mod(hueAdj, 2)-1))
+	centerX = new(big.Float).SetPrec(prec).Add(minX, maxX)
+	centerX.Quo(centerX, big.NewFloat(2).SetPrec(prec))
+	centerY = new(big.Float).SetPrec(prec).Add(minY, maxY)
+	centerY.Quo(centerY, big.NewFloat(2).SetPrec(prec))
 
-	var r, g, b float64
-	switch {
-	case hueAdj <= 1:
-		r, g, b = c, x, 0
-	case hueAdj <= 2:
-		r, g, b = x, c, 0
-	case hueAdj <= 3:
-		r, g, b = 0, c, x
-	case hueAdj <= 4:
-		r, g, b = 0, x, c
-	case hueAdj <= 5:
-		r, g, b = x, 0, c
-	case hueAdj <= 6:
-		r, g, b = c, 0, x
-	default:
-		panic(false)
-	}
+	pixelSpacing = new(big.Float).SetPrec(prec).Sub(maxX, minX)
+	pixelSpacing.Quo(pixelSpacing, big.NewFloat(tileSize).SetPrec(prec))
+	return centerX, centerY, pixelSpacing
+}
 
-	m := lightness - 0.5*c
-	r += m
-	g += m
-	b += m
+// renderTileDeepZoom renders a tile using the perturbation technique from
+// the deepzoom package, for zoom levels where tileExtent's plain float64
+// coordinates no longer have enough precision to distinguish neighbouring
+// pixels. The tile's centre and pixel spacing are instead derived directly
+// from the integer tile coordinates via bigTileGeometry, and a single
+// high-precision reference orbit is computed at that centre; every pixel
+// is then iterated as a float64 delta from it.
+func renderTileDeepZoom(renderer *render.Renderer, pal palette.Palette, coords TileCoords, tile *image.RGBA) {
+	centerX, centerY, pixelSpacing := bigTileGeometry(coords, deepzoom.PrecisionBits)
+	orbit := deepzoom.ComputeReferenceOrbit(centerX, centerY, maxIter)
 
-	if r < 0 || r > 1.0 {
-		panic(r)
-	}
-	if g < 0 || g > 1.0 {
-		panic(g)
-	}
-	if b < 0 || b > 1.0 {
-		panic(b)
-	}
+	renderer.RenderRows(tileSize, func(i int) {
+		dx := new(big.Float).SetPrec(deepzoom.PrecisionBits).Mul(pixelSpacing, big.NewFloat(float64(i)-tileSize/2))
+		dxF, _ := dx.Float64()
+
+		// Glitches tend to cluster (they share the same nearby low-period
+		// orbit), so the most recently computed second reference is tried
+		// before paying for a brand new one.
+		var cluster *glitchCluster
 
-	return color.RGBA{uint8(r * 0xff), uint8(g * 0xff), uint8(b * 0xff), 0xff}
+		for j := 0; j < tileSize; j++ {
+			dy := new(big.Float).SetPrec(deepzoom.PrecisionBits).Mul(pixelSpacing, big.NewFloat(float64(j)-tileSize/2))
+			dyF, _ := dy.Float64()
+			deltaC := complex(dxF, dyF)
+
+			iterationCount, glitched := deepzoom.IterateDelta(orbit, deltaC)
+			if glitched {
+				pointRe := new(big.Float).SetPrec(deepzoom.PrecisionBits).Add(centerX, dx)
+				pointIm := new(big.Float).SetPrec(deepzoom.PrecisionBits).Add(centerY, dy)
+				iterationCount, cluster = renderGlitchedPixel(pointRe, pointIm, cluster)
+			}
+			colour := pal.Lookup(iterationCount)
+			tile.SetRGBA(i, j, colour)
+		}
+	})
+}
+
+// glitchCluster is a second reference orbit computed for one glitched
+// pixel and then reused, via ordinary perturbation, for any other
+// glitched pixels nearby in the same row - glitches tend to cluster
+// around the same nearby low-period orbit, so this avoids recomputing a
+// full reference orbit for every single one.
+type glitchCluster struct {
+	re, im *big.Float
+	orbit  deepzoom.ReferenceOrbit
+}
+
+// renderGlitchedPixel re-renders a pixel whose delta orbit grew too close
+// to the tile's reference orbit to trust (see deepzoom.IterateDelta). It
+// first tries perturbing from cluster, the second reference orbit used by
+// the previous glitched pixel in this row; if that itself glitches (or
+// there isn't one yet), a new second reference orbit is computed at this
+// pixel's own point in the complex plane and returned as the cluster for
+// the next glitched pixel to try.
+func renderGlitchedPixel(pointRe, pointIm *big.Float, cluster *glitchCluster) (float64, *glitchCluster) {
+	if cluster != nil {
+		deltaRe, _ := new(big.Float).SetPrec(deepzoom.PrecisionBits).Sub(pointRe, cluster.re).Float64()
+		deltaIm, _ := new(big.Float).SetPrec(deepzoom.PrecisionBits).Sub(pointIm, cluster.im).Float64()
+		if iterationCount, glitched := deepzoom.IterateDelta(cluster.orbit, complex(deltaRe, deltaIm)); !glitched {
+			return iterationCount, cluster
+		}
+	}
+	orbit := deepzoom.ComputeReferenceOrbit(pointRe, pointIm, maxIter)
+	iterationCount, _ := deepzoom.IterateDelta(orbit, 0)
+	return iterationCount, &glitchCluster{re: pointRe, im: pointIm, orbit: orbit}
 }
 
 // mandelbrot returns 0 for numbers in the mandelbrot set, or the smoothed
 // iteration count before escape has been confirmed.
 func mandelbrot(c Vector) float64 {
-	const maxIter = 1000
 	var z Vector
 	iterate := func() {
 		z = Vector{z.X*z.X - z.Y*z.Y + c.X, 2*z.X*z.Y + c.Y}
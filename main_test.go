@@ -0,0 +1,58 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	"github.com/peterstace/mandelbrottiles/palette"
+	"github.com/peterstace/mandelbrottiles/render"
+)
+
+// TestRenderTileDeepZoomIsNotDegenerate exercises renderTile through the
+// same TileCoords -> tileExtent path real tile requests use, at a zoom
+// level (50) where tileExtent's plain float64 division is already well
+// past the deep-zoom threshold (see TestTileExtentCollapsesAtZ60 for a
+// depth where it has fully collapsed). X:0 puts the tile's left edge on
+// c=-2+0i, a Misiurewicz point on the set's boundary whose escape dynamics
+// stay fast at any zoom depth, so a correctly rendered tile there mixes
+// pixels still in the set with pixels that have just escaped. The
+// deep-zoom renderer must derive that mix from the tile's integer
+// coordinates rather than silently collapsing the whole tile to one flat
+// colour.
+func TestRenderTileDeepZoomIsNotDegenerate(t *testing.T) {
+	pal, ok := palette.Lookup("default")
+	if !ok {
+		t.Fatal("default palette not found")
+	}
+	renderer := render.New(2)
+
+	coords := TileCoords{Z: 50, X: 0, Y: 1 << 49}
+	img, ok := renderTile(renderer, pal, coords, nil).(*image.RGBA)
+	if !ok {
+		t.Fatalf("renderTile returned %T, want *image.RGBA", img)
+	}
+
+	bounds := img.Bounds()
+	distinct := map[[4]uint8]struct{}{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			distinct[[4]uint8{c.R, c.G, c.B, c.A}] = struct{}{}
+		}
+	}
+	if len(distinct) <= 1 {
+		t.Fatalf("tile at z=50 rendered only %d distinct colour(s); deep-zoom precision has collapsed", len(distinct))
+	}
+}
+
+// TestTileExtentCollapsesAtZ60 documents the float64 precision collapse
+// renderTileDeepZoom has to work around: at z=60, tileExtent itself can no
+// longer distinguish a tile from its neighbour, which is exactly why its
+// Extent can't be used to derive the deep-zoom reference point.
+func TestTileExtentCollapsesAtZ60(t *testing.T) {
+	e1 := tileExtent(TileCoords{Z: 60, X: 1 << 59, Y: 1 << 59})
+	e2 := tileExtent(TileCoords{Z: 60, X: 1<<59 + 1, Y: 1 << 59})
+	if e1 != e2 {
+		t.Fatal("expected tileExtent to have collapsed neighbouring tiles at z=60; update this test if float64 precision assumptions changed")
+	}
+}
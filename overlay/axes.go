@@ -0,0 +1,73 @@
+package overlay
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// axesColour is used for both axis lines and their tick marks.
+var axesColour = color.RGBA{0xff, 0x40, 0x40, 0xff}
+
+// tickSpacing is the spacing, in units of the complex plane, between tick
+// marks along each drawn axis.
+const tickSpacing = 0.5
+
+// tickHalfLength is how far a tick mark extends to either side of the
+// axis it sits on, in pixels.
+const tickHalfLength = 3
+
+// ComplexAxesOverlay draws the real and imaginary axes, plus tick marks,
+// wherever they intersect the tile's extent.
+type ComplexAxesOverlay struct{}
+
+// Draw implements Overlay.
+func (ComplexAxesOverlay) Draw(dst *image.RGBA, coords TileCoords, extent Extent) {
+	bounds := dst.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	toPixelX := func(x float64) int {
+		return bounds.Min.X + int((x-extent.MinX)/(extent.MaxX-extent.MinX)*float64(width))
+	}
+	toPixelY := func(y float64) int {
+		return bounds.Min.Y + int((y-extent.MinY)/(extent.MaxY-extent.MinY)*float64(height))
+	}
+
+	if extent.MinY <= 0 && 0 <= extent.MaxY {
+		py := toPixelY(0)
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dst.Set(x, py, axesColour)
+		}
+		for tick := math.Ceil(extent.MinX/tickSpacing) * tickSpacing; tick <= extent.MaxX; tick += tickSpacing {
+			drawVerticalTick(dst, toPixelX(tick), py, bounds)
+		}
+	}
+
+	if extent.MinX <= 0 && 0 <= extent.MaxX {
+		px := toPixelX(0)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			dst.Set(px, y, axesColour)
+		}
+		for tick := math.Ceil(extent.MinY/tickSpacing) * tickSpacing; tick <= extent.MaxY; tick += tickSpacing {
+			drawHorizontalTick(dst, px, toPixelY(tick), bounds)
+		}
+	}
+}
+
+func drawVerticalTick(dst *image.RGBA, x, axisY int, bounds image.Rectangle) {
+	for d := -tickHalfLength; d <= tickHalfLength; d++ {
+		y := axisY + d
+		if y >= bounds.Min.Y && y < bounds.Max.Y {
+			dst.Set(x, y, axesColour)
+		}
+	}
+}
+
+func drawHorizontalTick(dst *image.RGBA, axisX, y int, bounds image.Rectangle) {
+	for d := -tickHalfLength; d <= tickHalfLength; d++ {
+		x := axisX + d
+		if x >= bounds.Min.X && x < bounds.Max.X {
+			dst.Set(x, y, axesColour)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// gridColour is used for both the tile border and its z/x/y label.
+var gridColour = color.RGBA{0xff, 0xff, 0xff, 0xc0}
+
+// GridOverlay draws a border around the tile and labels it with its z/x/y
+// coordinates, useful for spotting misaligned tiles.
+type GridOverlay struct{}
+
+// Draw implements Overlay.
+func (GridOverlay) Draw(dst *image.RGBA, coords TileCoords, extent Extent) {
+	bounds := dst.Bounds()
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		dst.Set(x, bounds.Min.Y, gridColour)
+		dst.Set(x, bounds.Max.Y-1, gridColour)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		dst.Set(bounds.Min.X, y, gridColour)
+		dst.Set(bounds.Max.X-1, y, gridColour)
+	}
+
+	label := fmt.Sprintf("%d/%d/%d", coords.Z, coords.X, coords.Y)
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(gridColour),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(bounds.Min.X+4, bounds.Min.Y+14),
+	}
+	d.DrawString(label)
+}
@@ -0,0 +1,35 @@
+// Package overlay draws debugging and teaching annotations on top of an
+// already-rendered tile: grid lines with z/x/y labels, and the axes of the
+// complex plane.
+package overlay
+
+import "image"
+
+// TileCoords identifies the tile being drawn on, for overlays that label
+// tiles with their coordinates.
+type TileCoords struct {
+	Z, X, Y int
+}
+
+// Extent is the rectangular region of the complex plane a tile covers.
+type Extent struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// Overlay draws annotations on top of an already-rendered tile, in place.
+type Overlay interface {
+	Draw(dst *image.RGBA, coords TileCoords, extent Extent)
+}
+
+// byName is the registry of overlays selectable via the -overlays flag.
+var byName = map[string]Overlay{
+	"grid": GridOverlay{},
+	"axes": ComplexAxesOverlay{},
+}
+
+// Lookup returns the named overlay, or false if no overlay is registered
+// under that name.
+func Lookup(name string) (Overlay, bool) {
+	o, ok := byName[name]
+	return o, ok
+}
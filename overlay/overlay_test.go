@@ -0,0 +1,46 @@
+package overlay
+
+import (
+	"image"
+	"testing"
+)
+
+func TestGridOverlayDrawsBorder(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	GridOverlay{}.Draw(dst, TileCoords{Z: 3, X: 1, Y: 2}, Extent{MinX: -1, MinY: -1, MaxX: 1, MaxY: 1})
+
+	if dst.RGBAAt(0, 0) != gridColour {
+		t.Error("expected the top-left corner to be part of the border")
+	}
+	if dst.RGBAAt(8, 8) == gridColour {
+		t.Error("expected the tile centre to be untouched by the border")
+	}
+}
+
+func TestComplexAxesOverlayDrawsAxesOnlyWhenTheyIntersectTheTile(t *testing.T) {
+	straddling := Extent{MinX: -1, MinY: -1, MaxX: 1, MaxY: 1}
+	dst := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	ComplexAxesOverlay{}.Draw(dst, TileCoords{}, straddling)
+	if dst.RGBAAt(8, 8) != axesColour {
+		t.Error("expected the axes to cross through the centre of a tile straddling the origin")
+	}
+
+	awayFromOrigin := Extent{MinX: 10, MinY: 10, MaxX: 11, MaxY: 11}
+	dst2 := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	ComplexAxesOverlay{}.Draw(dst2, TileCoords{}, awayFromOrigin)
+	if dst2.RGBAAt(8, 8) == axesColour {
+		t.Error("expected no axes drawn on a tile that doesn't straddle the origin")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if _, ok := Lookup("grid"); !ok {
+		t.Error(`Lookup("grid") not found`)
+	}
+	if _, ok := Lookup("axes"); !ok {
+		t.Error(`Lookup("axes") not found`)
+	}
+	if _, ok := Lookup("nonexistent"); ok {
+		t.Error(`Lookup("nonexistent") should not be found`)
+	}
+}
@@ -0,0 +1,183 @@
+// Package palette provides named colour palettes for mapping Mandelbrot
+// escape iteration counts onto RGBA colours.
+package palette
+
+import (
+	"image/color"
+	"math"
+)
+
+// Palette maps a smoothed escape iteration count onto a colour.
+type Palette interface {
+	Lookup(iter float64) color.RGBA
+}
+
+// PaletteFunc adapts a plain function to the Palette interface.
+type PaletteFunc func(iter float64) color.RGBA
+
+// Lookup calls f.
+func (f PaletteFunc) Lookup(iter float64) color.RGBA {
+	return f(iter)
+}
+
+// resolution is the number of entries in each InterpolatedPalette's lookup
+// table.
+const resolution = 4096
+
+// Default is the original HSL sweep, kept as-is for backwards compatibility.
+var Default Palette = PaletteFunc(hslSweep)
+
+func hslSweep(iterationCount float64) color.RGBA {
+	iterationCount *= 25 // artistically chosen multiplier
+	return hslToRGB(math.Mod(iterationCount+360, 360), 0.5, 0.5)
+}
+
+func hslToRGB(hue, saturation, lightness float64) color.RGBA {
+	if hue < 0 || hue > 360 {
+		panic("hue must be from 0 to 360")
+	}
+	if saturation < 0 || saturation > 1 {
+		panic("saturation must be between 0 and 1")
+	}
+	if lightness < 0 || lightness > 1 {
+		panic("lightness must be between 0 and 1")
+	}
+
+	c := (1 - math.Abs(2*lightness-1)) * saturation // chroma
+	hueAdj := hue / 60
+	x := c * (1 - math.Abs(math.Mod(hueAdj, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case hueAdj <= 1:
+		r, g, b = c, x, 0
+	case hueAdj <= 2:
+		r, g, b = x, c, 0
+	case hueAdj <= 3:
+		r, g, b = 0, c, x
+	case hueAdj <= 4:
+		r, g, b = 0, x, c
+	case hueAdj <= 5:
+		r, g, b = x, 0, c
+	case hueAdj <= 6:
+		r, g, b = c, 0, x
+	default:
+		panic(false)
+	}
+
+	m := lightness - 0.5*c
+	r += m
+	g += m
+	b += m
+
+	if r < 0 || r > 1.0 {
+		panic(r)
+	}
+	if g < 0 || g > 1.0 {
+		panic(g)
+	}
+	if b < 0 || b > 1.0 {
+		panic(b)
+	}
+
+	return color.RGBA{uint8(r * 0xff), uint8(g * 0xff), uint8(b * 0xff), 0xff}
+}
+
+// Plan9, Hippi, AfternoonBlue and Fiesta are fixed-stop interpolated
+// palettes, named after the aesthetics they're going for.
+var (
+	Plan9 = NewInterpolatedPalette([]color.RGBA{
+		{0x00, 0x00, 0x00, 0xff},
+		{0x1a, 0x33, 0x66, 0xff},
+		{0x33, 0x99, 0xcc, 0xff},
+		{0xff, 0xff, 0xff, 0xff},
+	}, resolution)
+
+	Hippi = NewInterpolatedPalette([]color.RGBA{
+		{0x2d, 0x00, 0x40, 0xff},
+		{0x7a, 0x1f, 0xa2, 0xff},
+		{0x2e, 0xc4, 0x4f, 0xff},
+		{0xff, 0xf3, 0x8c, 0xff},
+	}, resolution)
+
+	AfternoonBlue = NewInterpolatedPalette([]color.RGBA{
+		{0x00, 0x08, 0x22, 0xff},
+		{0x08, 0x2f, 0x66, 0xff},
+		{0x3d, 0x7e, 0xcf, 0xff},
+		{0xd6, 0xec, 0xff, 0xff},
+	}, resolution)
+
+	Fiesta = NewInterpolatedPalette([]color.RGBA{
+		{0x3a, 0x00, 0x00, 0xff},
+		{0xd7, 0x26, 0x1c, 0xff},
+		{0xf4, 0xa2, 0x24, 0xff},
+		{0xfd, 0xf2, 0xb3, 0xff},
+	}, resolution)
+)
+
+// byName is the registry of palettes selectable via the -palette flag.
+var byName = map[string]Palette{
+	"default":       Default,
+	"plan9":         Plan9,
+	"hippi":         Hippi,
+	"afternoonblue": AfternoonBlue,
+	"fiesta":        Fiesta,
+}
+
+// Lookup returns the named palette, or false if no palette is registered
+// under that name.
+func Lookup(name string) (Palette, bool) {
+	p, ok := byName[name]
+	return p, ok
+}
+
+// InterpolatedPalette is a Palette backed by a precomputed lookup table,
+// built by linearly interpolating between a small list of anchor colour
+// stops.
+type InterpolatedPalette struct {
+	table []color.RGBA
+}
+
+// NewInterpolatedPalette expands stops into a lookup table with resolution
+// entries, linearly interpolating between adjacent stops. It panics if
+// fewer than two stops are given.
+func NewInterpolatedPalette(stops []color.RGBA, resolution int) *InterpolatedPalette {
+	if len(stops) < 2 {
+		panic("at least two colour stops are required")
+	}
+	segments := len(stops) - 1
+	table := make([]color.RGBA, resolution)
+	for i := range table {
+		t := float64(i) / float64(resolution-1) * float64(segments)
+		seg := int(t)
+		if seg >= segments {
+			seg = segments - 1
+		}
+		table[i] = lerpRGBA(stops[seg], stops[seg+1], t-float64(seg))
+	}
+	return &InterpolatedPalette{table: table}
+}
+
+// Lookup maps iter onto a table entry, wrapping around the table so that
+// deep iteration counts cycle back through the palette rather than
+// clamping to a single colour.
+func (p *InterpolatedPalette) Lookup(iter float64) color.RGBA {
+	n := len(p.table)
+	idx := int(iter) % n
+	if idx < 0 {
+		idx += n
+	}
+	return p.table[idx]
+}
+
+func lerpRGBA(a, b color.RGBA, t float64) color.RGBA {
+	lerp := func(a, b uint8, t float64) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return color.RGBA{
+		R: lerp(a.R, b.R, t),
+		G: lerp(a.G, b.G, t),
+		B: lerp(a.B, b.B, t),
+		A: lerp(a.A, b.A, t),
+	}
+}
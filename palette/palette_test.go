@@ -0,0 +1,43 @@
+package palette
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestInterpolatedPaletteEndpointsMatchStops(t *testing.T) {
+	stops := []color.RGBA{
+		{0x00, 0x00, 0x00, 0xff},
+		{0xff, 0xff, 0xff, 0xff},
+	}
+	p := NewInterpolatedPalette(stops, 16)
+
+	if got := p.Lookup(0); got != stops[0] {
+		t.Errorf("first entry: got %v, want %v", got, stops[0])
+	}
+	if got := p.Lookup(15); got != stops[1] {
+		t.Errorf("last entry: got %v, want %v", got, stops[1])
+	}
+}
+
+func TestInterpolatedPaletteWraps(t *testing.T) {
+	p := NewInterpolatedPalette([]color.RGBA{
+		{0x00, 0x00, 0x00, 0xff},
+		{0xff, 0xff, 0xff, 0xff},
+	}, 16)
+
+	if got, want := p.Lookup(16), p.Lookup(0); got != want {
+		t.Errorf("Lookup(16) = %v, want it to wrap to Lookup(0) = %v", got, want)
+	}
+}
+
+func TestLookupByName(t *testing.T) {
+	for _, name := range []string{"default", "plan9", "hippi", "afternoonblue", "fiesta"} {
+		if _, ok := Lookup(name); !ok {
+			t.Errorf("Lookup(%q) not found in registry", name)
+		}
+	}
+	if _, ok := Lookup("nonexistent"); ok {
+		t.Error("Lookup(\"nonexistent\") should not be found")
+	}
+}
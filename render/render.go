@@ -0,0 +1,54 @@
+// Package render provides a bounded worker pool for computing tile pixels in
+// parallel, so a single HTTP request doesn't serialize 65,536 pixel
+// evaluations onto one goroutine.
+package render
+
+import "sync"
+
+// job is a unit of work dispatched to a worker: compute every pixel in the
+// given row by calling fn, then signal wg.
+type job struct {
+	row int
+	fn  func(row int)
+	wg  *sync.WaitGroup
+}
+
+// Renderer is a fixed-size pool of worker goroutines that render tile rows.
+// A single Renderer is started once at server boot and shared across all
+// incoming requests.
+type Renderer struct {
+	jobs chan job
+}
+
+// New starts a Renderer with the given number of workers. workers must be at
+// least 1.
+func New(workers int) *Renderer {
+	if workers < 1 {
+		workers = 1
+	}
+	r := &Renderer{jobs: make(chan job, workers)}
+	for i := 0; i < workers; i++ {
+		go r.work()
+	}
+	return r
+}
+
+func (r *Renderer) work() {
+	for j := range r.jobs {
+		j.fn(j.row)
+		j.wg.Done()
+	}
+}
+
+// RenderRows calls fn(row) once for each row in [0, rows), spreading the
+// calls across the worker pool, and blocks until every row has completed.
+// Rows (rather than individual pixels) are the unit of work so that channel
+// overhead doesn't dominate for cheap pixels.
+func (r *Renderer) RenderRows(rows int, fn func(row int)) {
+	var wg sync.WaitGroup
+	wg.Add(rows)
+	for i := 0; i < rows; i++ {
+		r.jobs <- job{row: i, fn: fn, wg: &wg}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,55 @@
+package render
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestRenderRowsCallsEveryRowExactlyOnce(t *testing.T) {
+	const rows = 256
+	var counts [rows]int32
+
+	r := New(4)
+	r.RenderRows(rows, func(row int) {
+		atomic.AddInt32(&counts[row], 1)
+	})
+
+	for row, count := range counts {
+		if count != 1 {
+			t.Errorf("row %d: got %d calls, want 1", row, count)
+		}
+	}
+}
+
+// heavyPixel simulates a pixel near the boundary of the Mandelbrot set,
+// where the iteration count (and therefore the work per pixel) is largest.
+func heavyPixel() float64 {
+	const maxIter = 1000
+	x, y := 0.001, 0.001
+	var zx, zy float64
+	var i int
+	for ; i < maxIter; i++ {
+		zx, zy = zx*zx-zy*zy+x, 2*zx*zy+y
+		if zx*zx+zy*zy > 4 {
+			break
+		}
+	}
+	return float64(i)
+}
+
+func benchmarkTile(b *testing.B, workers int) {
+	const tileSize = 256
+	r := New(workers)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		r.RenderRows(tileSize, func(row int) {
+			for col := 0; col < tileSize; col++ {
+				_ = heavyPixel()
+			}
+		})
+	}
+}
+
+func BenchmarkTileSingleWorker(b *testing.B) { benchmarkTile(b, 1) }
+func BenchmarkTileFourWorkers(b *testing.B)  { benchmarkTile(b, 4) }
+func BenchmarkTileEightWorkers(b *testing.B) { benchmarkTile(b, 8) }
@@ -0,0 +1,166 @@
+// Package tilearchive packs rendered tiles into a single-file archive: a
+// fixed-size header, a root directory of tile entries (spilling into leaf
+// directories once there are too many entries for the root alone), and a
+// tile data section, with tiles ordered by Hilbert curve tile ID so that
+// nearby tiles end up physically nearby in the file, and served via range
+// reads so the file can be hosted from static object storage.
+//
+// This is a bespoke format inspired by PMTiles, not an implementation of
+// it: directory entries here are fixed-width rather than delta/varint
+// encoded, and the magic bytes and on-disk layout are this package's own.
+// Files it writes are not readable by pmtiles-ecosystem tools (the
+// upstream `pmtiles` CLI, MapLibre's protomaps plugin, etc.), so they
+// should not use the .pmtiles extension or be described as PMTiles
+// archives.
+package tilearchive
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// magic identifies a file as belonging to this package.
+var magic = [7]byte{'M', 'T', 'i', 'l', 'e', 's', 'A'}
+
+const formatVersion = 1
+
+// headerSize is the fixed, on-disk size in bytes of Header.
+const headerSize = 7 + 1 + 1 + // magic, version, pad
+	8 + 8 + // root directory offset, length
+	8 + 8 + // leaf directories offset, length
+	8 + 8 + // tile data offset, length
+	8 + // tile count
+	1 + 1 + // min zoom, max zoom
+	8*4 // bounds: min x, min y, max x, max y
+
+// Header describes the layout of an archive and the bounds of the tiles it
+// contains.
+type Header struct {
+	RootDirOffset, RootDirLength   uint64
+	LeafDirsOffset, LeafDirsLength uint64
+	TileDataOffset, TileDataLength uint64
+	TileCount                      uint64
+	MinZoom, MaxZoom               uint8
+	MinX, MinY, MaxX, MaxY         float64 // bounds, in the renderer's coordinate space
+}
+
+func (h Header) marshal() []byte {
+	buf := make([]byte, headerSize)
+	copy(buf[0:7], magic[:])
+	buf[7] = formatVersion
+	off := 9
+	putU64 := func(v uint64) {
+		binary.BigEndian.PutUint64(buf[off:], v)
+		off += 8
+	}
+	putU64(h.RootDirOffset)
+	putU64(h.RootDirLength)
+	putU64(h.LeafDirsOffset)
+	putU64(h.LeafDirsLength)
+	putU64(h.TileDataOffset)
+	putU64(h.TileDataLength)
+	putU64(h.TileCount)
+	buf[off] = h.MinZoom
+	buf[off+1] = h.MaxZoom
+	off += 2
+	putFloat := func(v float64) {
+		binary.BigEndian.PutUint64(buf[off:], math.Float64bits(v))
+		off += 8
+	}
+	putFloat(h.MinX)
+	putFloat(h.MinY)
+	putFloat(h.MaxX)
+	putFloat(h.MaxY)
+	return buf
+}
+
+func unmarshalHeader(buf []byte) (Header, error) {
+	if len(buf) != headerSize {
+		return Header{}, fmt.Errorf("tilearchive: header is %d bytes, want %d", len(buf), headerSize)
+	}
+	if string(buf[0:7]) != string(magic[:]) {
+		return Header{}, fmt.Errorf("tilearchive: bad magic bytes")
+	}
+	if buf[7] != formatVersion {
+		return Header{}, fmt.Errorf("tilearchive: unsupported format version %d", buf[7])
+	}
+
+	var h Header
+	off := 9
+	getU64 := func() uint64 {
+		v := binary.BigEndian.Uint64(buf[off:])
+		off += 8
+		return v
+	}
+	h.RootDirOffset = getU64()
+	h.RootDirLength = getU64()
+	h.LeafDirsOffset = getU64()
+	h.LeafDirsLength = getU64()
+	h.TileDataOffset = getU64()
+	h.TileDataLength = getU64()
+	h.TileCount = getU64()
+	h.MinZoom = buf[off]
+	h.MaxZoom = buf[off+1]
+	off += 2
+	getFloat := func() float64 {
+		v := math.Float64frombits(binary.BigEndian.Uint64(buf[off:]))
+		off += 8
+		return v
+	}
+	h.MinX = getFloat()
+	h.MinY = getFloat()
+	h.MaxX = getFloat()
+	h.MaxY = getFloat()
+	return h, nil
+}
+
+// entrySize is the fixed, on-disk size in bytes of a directory entry.
+const entrySize = 8 + 8 + 4 + 4
+
+// entryKindLeaf marks an entry in the root directory as pointing at a leaf
+// directory rather than at tile data.
+const entryKindLeaf = 0
+
+// entryKindTile marks an entry as pointing directly at tile data.
+const entryKindTile = 1
+
+// entry is one record in a directory. TileID is the first (for a leaf
+// pointer) or only (for a tile entry) Hilbert tile ID it describes.
+// Offset and Length locate the bytes it points at, relative to
+// LeafDirsOffset for a leaf pointer or TileDataOffset for a tile entry.
+type entry struct {
+	TileID uint64
+	Offset uint64
+	Length uint32
+	Kind   uint32
+}
+
+func marshalEntries(entries []entry) []byte {
+	buf := make([]byte, len(entries)*entrySize)
+	for i, e := range entries {
+		b := buf[i*entrySize:]
+		binary.BigEndian.PutUint64(b[0:], e.TileID)
+		binary.BigEndian.PutUint64(b[8:], e.Offset)
+		binary.BigEndian.PutUint32(b[16:], e.Length)
+		binary.BigEndian.PutUint32(b[20:], e.Kind)
+	}
+	return buf
+}
+
+func unmarshalEntries(buf []byte) ([]entry, error) {
+	if len(buf)%entrySize != 0 {
+		return nil, fmt.Errorf("tilearchive: directory is %d bytes, not a multiple of entry size %d", len(buf), entrySize)
+	}
+	entries := make([]entry, len(buf)/entrySize)
+	for i := range entries {
+		b := buf[i*entrySize:]
+		entries[i] = entry{
+			TileID: binary.BigEndian.Uint64(b[0:]),
+			Offset: binary.BigEndian.Uint64(b[8:]),
+			Length: binary.BigEndian.Uint32(b[16:]),
+			Kind:   binary.BigEndian.Uint32(b[20:]),
+		}
+	}
+	return entries, nil
+}
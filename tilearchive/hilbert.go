@@ -0,0 +1,71 @@
+package tilearchive
+
+// ZXYToID maps a tile's (zoom, x, y) coordinates onto a single uint64,
+// ordered so that tiles at a given zoom level are contiguous and visited
+// in Hilbert-curve order, and every tile at zoom z sorts before every tile
+// at zoom z+1. This is the ordering an archive written by Pack stores its
+// tiles in, so that spatially nearby tiles tend to end up physically
+// nearby on disk.
+func ZXYToID(z uint8, x, y uint32) uint64 {
+	var acc uint64
+	for t := uint8(0); t < z; t++ {
+		acc += tilesAtZoom(t)
+	}
+	return acc + hilbertD(z, x, y)
+}
+
+// zxyAtD returns the (x, y) coordinates of the d'th tile, in Hilbert-curve
+// order, at zoom level z. It's the inverse of hilbertD, used by Pack to
+// generate tiles directly in on-disk order rather than rendering them in
+// row-major order and sorting afterwards.
+func zxyAtD(z uint8, d uint64) (x, y uint32) {
+	n := uint32(1) << z
+	for s := uint32(1); s < n; s *= 2 {
+		rx := uint32(1 & (d / 2))
+		ry := uint32(1 & (d ^ uint64(rx)))
+		if ry == 0 {
+			if rx == 1 {
+				x = s - 1 - x
+				y = s - 1 - y
+			}
+			x, y = y, x
+		}
+		x += s * rx
+		y += s * ry
+		d /= 4
+	}
+	return x, y
+}
+
+// tilesAtZoom is the number of distinct tiles at zoom level z: 4^z.
+func tilesAtZoom(z uint8) uint64 {
+	return uint64(1) << (2 * z)
+}
+
+// hilbertD converts (x, y) on a 2^z by 2^z grid into its distance along the
+// Hilbert curve, using the standard xy-to-d algorithm.
+func hilbertD(z uint8, x, y uint32) uint64 {
+	n := uint32(1) << z
+	var d uint64
+	tx, ty := x, y
+	for s := n / 2; s > 0; s /= 2 {
+		var rx, ry uint32
+		if tx&s > 0 {
+			rx = 1
+		}
+		if ty&s > 0 {
+			ry = 1
+		}
+		d += uint64(s) * uint64(s) * uint64((3*rx)^ry)
+
+		// Rotate the quadrant.
+		if ry == 0 {
+			if rx == 1 {
+				tx = s - 1 - tx
+				ty = s - 1 - ty
+			}
+			tx, ty = ty, tx
+		}
+	}
+	return d
+}
@@ -0,0 +1,91 @@
+package tilearchive
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Reader serves tiles out of an archive written by Pack, using range reads
+// against ra so the archive can be hosted anywhere that supports them
+// (including static object storage, not just a local file).
+type Reader struct {
+	ra     io.ReaderAt
+	Header Header
+	root   []entry
+}
+
+// Open reads an archive's header and root directory from ra. It doesn't
+// read leaf directories or tile data until a tile is requested.
+func Open(ra io.ReaderAt) (*Reader, error) {
+	headerBuf := make([]byte, headerSize)
+	if _, err := ra.ReadAt(headerBuf, 0); err != nil {
+		return nil, fmt.Errorf("tilearchive: reading header: %w", err)
+	}
+	header, err := unmarshalHeader(headerBuf)
+	if err != nil {
+		return nil, err
+	}
+
+	rootBuf := make([]byte, header.RootDirLength)
+	if _, err := ra.ReadAt(rootBuf, int64(header.RootDirOffset)); err != nil {
+		return nil, fmt.Errorf("tilearchive: reading root directory: %w", err)
+	}
+	root, err := unmarshalEntries(rootBuf)
+	if err != nil {
+		return nil, fmt.Errorf("tilearchive: root directory: %w", err)
+	}
+
+	return &Reader{ra: ra, Header: header, root: root}, nil
+}
+
+// GetTile returns the encoded tile bytes for z/x/y, and false if the
+// archive has no such tile.
+func (r *Reader) GetTile(z uint8, x, y uint32) ([]byte, bool, error) {
+	id := ZXYToID(z, x, y)
+
+	e, ok, err := r.findEntry(r.root, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	if e.Kind == entryKindLeaf {
+		leafBuf := make([]byte, e.Length)
+		if _, err := r.ra.ReadAt(leafBuf, int64(r.Header.LeafDirsOffset)+int64(e.Offset)); err != nil {
+			return nil, false, fmt.Errorf("tilearchive: reading leaf directory: %w", err)
+		}
+		leaf, err := unmarshalEntries(leafBuf)
+		if err != nil {
+			return nil, false, fmt.Errorf("tilearchive: leaf directory: %w", err)
+		}
+		e, ok, err = r.findEntry(leaf, id)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+	}
+
+	data := make([]byte, e.Length)
+	if _, err := r.ra.ReadAt(data, int64(r.Header.TileDataOffset)+int64(e.Offset)); err != nil {
+		return nil, false, fmt.Errorf("tilearchive: reading tile data: %w", err)
+	}
+	return data, true, nil
+}
+
+// findEntry looks up id in entries, which must be sorted by TileID. For a
+// root directory full of leaf pointers, it returns the pointer covering
+// the range that id falls into.
+func (r *Reader) findEntry(entries []entry, id uint64) (entry, bool, error) {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].TileID > id })
+	i--
+	if i < 0 {
+		return entry{}, false, nil
+	}
+	e := entries[i]
+	if e.Kind == entryKindTile && e.TileID != id {
+		return entry{}, false, nil
+	}
+	return e, true, nil
+}
@@ -0,0 +1,167 @@
+package tilearchive
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestZXYToIDOrdersByZoomThenHilbertCurve(t *testing.T) {
+	// Every tile ID at zoom z must be less than every tile ID at zoom z+1.
+	for z := uint8(0); z < 4; z++ {
+		n := uint32(1) << z
+		var maxAtZ uint64
+		for x := uint32(0); x < n; x++ {
+			for y := uint32(0); y < n; y++ {
+				id := ZXYToID(z, x, y)
+				if id > maxAtZ {
+					maxAtZ = id
+				}
+			}
+		}
+		minAtNextZ := ZXYToID(z+1, 0, 0)
+		if minAtNextZ <= maxAtZ {
+			t.Errorf("zoom %d: max id %d should be less than the next zoom's min id %d", z, maxAtZ, minAtNextZ)
+		}
+	}
+}
+
+func TestZxyAtDIsInverseOfHilbertD(t *testing.T) {
+	for z := uint8(0); z < 6; z++ {
+		n := tilesAtZoom(z)
+		for d := uint64(0); d < n; d++ {
+			x, y := zxyAtD(z, d)
+			if got := hilbertD(z, x, y); got != d {
+				t.Fatalf("z=%d d=%d: zxyAtD gave (%d, %d), but hilbertD of that is %d", z, d, x, y, got)
+			}
+		}
+	}
+}
+
+func TestZXYToIDIsUniquePerTile(t *testing.T) {
+	seen := make(map[uint64]struct{})
+	for z := uint8(0); z < 5; z++ {
+		n := uint32(1) << z
+		for x := uint32(0); x < n; x++ {
+			for y := uint32(0); y < n; y++ {
+				id := ZXYToID(z, x, y)
+				if _, ok := seen[id]; ok {
+					t.Fatalf("duplicate tile id %d for z=%d x=%d y=%d", id, z, x, y)
+				}
+				seen[id] = struct{}{}
+			}
+		}
+	}
+}
+
+func TestPackAndReadRoundTrip(t *testing.T) {
+	const maxZoom = 3
+	source := func(z, x, y int) ([]byte, error) {
+		return []byte(fmt.Sprintf("tile %d/%d/%d", z, x, y)), nil
+	}
+
+	var buf bytes.Buffer
+	opts := PackOptions{MinZoom: 0, MaxZoom: maxZoom, MinX: -2, MinY: -2, MaxX: 2, MaxY: 2}
+	if err := Pack(&buf, opts, source); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.MinZoom != 0 || r.Header.MaxZoom != maxZoom {
+		t.Errorf("got zoom range [%d, %d], want [0, %d]", r.Header.MinZoom, r.Header.MaxZoom, maxZoom)
+	}
+
+	for z := 0; z <= maxZoom; z++ {
+		n := 1 << z
+		for x := 0; x < n; x++ {
+			for y := 0; y < n; y++ {
+				want, _ := source(z, x, y)
+				got, ok, err := r.GetTile(uint8(z), uint32(x), uint32(y))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					t.Fatalf("tile %d/%d/%d missing", z, x, y)
+				}
+				if string(got) != string(want) {
+					t.Errorf("tile %d/%d/%d: got %q, want %q", z, x, y, got, want)
+				}
+			}
+		}
+	}
+
+	if _, ok, err := r.GetTile(maxZoom+1, 0, 0); err != nil || ok {
+		t.Errorf("tile beyond max zoom: ok=%v err=%v, want ok=false err=nil", ok, err)
+	}
+}
+
+func TestPackAndReadRoundTripWithLeafDirectories(t *testing.T) {
+	// maxZoom is chosen so the tile count (sum of 4^z for z in
+	// [0, maxZoom]) exceeds leafCapacity, forcing entries to spill out of
+	// the root directory into leaf directories.
+	const maxZoom = 6
+	source := func(z, x, y int) ([]byte, error) {
+		return []byte(fmt.Sprintf("tile %d/%d/%d", z, x, y)), nil
+	}
+
+	var buf bytes.Buffer
+	opts := PackOptions{MinZoom: 0, MaxZoom: maxZoom, MinX: -2, MinY: -2, MaxX: 2, MaxY: 2}
+	if err := Pack(&buf, opts, source); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.TileCount <= leafCapacity {
+		t.Fatalf("tile count %d must exceed leafCapacity %d for this test to exercise leaf directories", r.Header.TileCount, leafCapacity)
+	}
+	if r.Header.LeafDirsLength == 0 {
+		t.Fatal("expected entries to spill into leaf directories, but LeafDirsLength is 0")
+	}
+
+	for z := 0; z <= maxZoom; z++ {
+		n := 1 << z
+		for x := 0; x < n; x++ {
+			for y := 0; y < n; y++ {
+				want, _ := source(z, x, y)
+				got, ok, err := r.GetTile(uint8(z), uint32(x), uint32(y))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					t.Fatalf("tile %d/%d/%d missing", z, x, y)
+				}
+				if string(got) != string(want) {
+					t.Errorf("tile %d/%d/%d: got %q, want %q", z, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPackDeduplicatesIdenticalTiles(t *testing.T) {
+	source := func(z, x, y int) ([]byte, error) {
+		return []byte("all black interior tile"), nil
+	}
+
+	var buf bytes.Buffer
+	opts := PackOptions{MinZoom: 0, MaxZoom: 2}
+	if err := Pack(&buf, opts, source); err != nil {
+		t.Fatal(err)
+	}
+
+	// An archive where every tile is identical should need only a single
+	// copy of the tile bytes, not one per tile.
+	r, err := Open(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Header.TileDataLength, uint64(len("all black interior tile")); got != want {
+		t.Errorf("tile data section is %d bytes, want %d (a single deduplicated copy)", got, want)
+	}
+}
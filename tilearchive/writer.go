@@ -0,0 +1,123 @@
+package tilearchive
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+)
+
+// leafCapacity is the maximum number of entries the root directory holds
+// directly before entries spill into leaf directories, one per
+// leafCapacity-sized chunk of the (Hilbert-ordered) tile set.
+const leafCapacity = 4096
+
+// TileSource renders (or otherwise produces) the encoded tile bytes for a
+// single z/x/y coordinate, e.g. a PNG.
+type TileSource func(z, x, y int) ([]byte, error)
+
+// PackOptions configures Pack.
+type PackOptions struct {
+	MinZoom, MaxZoom       int
+	MinX, MinY, MaxX, MaxY float64 // bounds of the tile set, in the renderer's coordinate space
+}
+
+// Pack renders every tile from opts.MinZoom to opts.MaxZoom using source
+// and writes them to w as a single archive, ordered by Hilbert curve tile
+// ID. Byte-identical tiles (the all-black interior of the Mandelbrot set,
+// for example) are stored once and referenced by every entry that needs
+// them.
+//
+// Tiles are rendered and written to a scratch file one at a time, in ID
+// order, rather than held in memory as a single slice: a full-depth
+// archive can run into the millions of tiles, and buffering every encoded
+// PNG at once is impractical.
+func Pack(w io.Writer, opts PackOptions, source TileSource) error {
+	tileData, err := os.CreateTemp("", "tilearchive-data-*")
+	if err != nil {
+		return fmt.Errorf("tilearchive: creating scratch file for tile data: %w", err)
+	}
+	defer os.Remove(tileData.Name())
+	defer tileData.Close()
+
+	// Render tiles and write their (deduplicated) bytes to the scratch
+	// file in ID order, recording an entry per tile as we go.
+	var entries []entry
+	var tileDataLen uint64
+	seen := make(map[[sha1.Size]byte]struct{ offset, length uint64 })
+	var zOffset uint64
+	for z := opts.MinZoom; z <= opts.MaxZoom; z++ {
+		n := tilesAtZoom(uint8(z))
+		for d := uint64(0); d < n; d++ {
+			x, y := zxyAtD(uint8(z), d)
+			data, err := source(z, int(x), int(y))
+			if err != nil {
+				return fmt.Errorf("rendering tile %d/%d/%d: %w", z, x, y, err)
+			}
+
+			sum := sha1.Sum(data)
+			loc, ok := seen[sum]
+			if !ok {
+				if _, err := tileData.Write(data); err != nil {
+					return fmt.Errorf("tilearchive: writing tile data: %w", err)
+				}
+				loc = struct{ offset, length uint64 }{tileDataLen, uint64(len(data))}
+				tileDataLen += uint64(len(data))
+				seen[sum] = loc
+			}
+			entries = append(entries, entry{TileID: zOffset + d, Offset: loc.offset, Length: uint32(loc.length), Kind: entryKindTile})
+		}
+		zOffset += n
+	}
+
+	var leafDirs []byte
+	var root []entry
+	if len(entries) <= leafCapacity {
+		root = entries
+	} else {
+		for start := 0; start < len(entries); start += leafCapacity {
+			end := start + leafCapacity
+			if end > len(entries) {
+				end = len(entries)
+			}
+			chunk := marshalEntries(entries[start:end])
+			root = append(root, entry{
+				TileID: entries[start].TileID,
+				Offset: uint64(len(leafDirs)),
+				Length: uint32(len(chunk)),
+				Kind:   entryKindLeaf,
+			})
+			leafDirs = append(leafDirs, chunk...)
+		}
+	}
+	rootBytes := marshalEntries(root)
+
+	header := Header{
+		RootDirLength:  uint64(len(rootBytes)),
+		LeafDirsLength: uint64(len(leafDirs)),
+		TileDataLength: tileDataLen,
+		TileCount:      uint64(len(entries)),
+		MinZoom:        uint8(opts.MinZoom),
+		MaxZoom:        uint8(opts.MaxZoom),
+		MinX:           opts.MinX,
+		MinY:           opts.MinY,
+		MaxX:           opts.MaxX,
+		MaxY:           opts.MaxY,
+	}
+	header.RootDirOffset = headerSize
+	header.LeafDirsOffset = header.RootDirOffset + header.RootDirLength
+	header.TileDataOffset = header.LeafDirsOffset + header.LeafDirsLength
+
+	for _, section := range [][]byte{header.marshal(), rootBytes, leafDirs} {
+		if _, err := w.Write(section); err != nil {
+			return err
+		}
+	}
+	if _, err := tileData.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("tilearchive: rewinding tile data scratch file: %w", err)
+	}
+	if _, err := io.Copy(w, tileData); err != nil {
+		return fmt.Errorf("tilearchive: copying tile data: %w", err)
+	}
+	return nil
+}